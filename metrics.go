@@ -0,0 +1,209 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// responseDelegator wraps an http.ResponseWriter to record the status code
+// and number of bytes written through it, so that ServeHTTP and AccessLog
+// can report on the real write to the client rather than guessing at it.
+type responseDelegator struct {
+	http.ResponseWriter
+	status  int
+	written int64
+	wrote   bool
+}
+
+func newResponseDelegator(w http.ResponseWriter) *responseDelegator {
+	return &responseDelegator{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (d *responseDelegator) WriteHeader(code int) {
+	if !d.wrote {
+		d.status = code
+		d.wrote = true
+	}
+	d.ResponseWriter.WriteHeader(code)
+}
+
+func (d *responseDelegator) Write(b []byte) (int, error) {
+	if !d.wrote {
+		d.WriteHeader(http.StatusOK)
+	}
+	n, err := d.ResponseWriter.Write(b)
+	d.written += int64(n)
+	return n, err
+}
+
+// ResponseInfo describes one TwiML callback handled by a HandlerFunc. It is
+// passed to OnResponse hooks and to a Collector's Observe method.
+type ResponseInfo struct {
+	Path     string
+	Method   string
+	Status   int
+	Bytes    int64
+	Duration time.Duration
+
+	// From, To and CallSid are the corresponding Twilio request
+	// parameters, included for correlating a response with the call
+	// that produced it.
+	From    string
+	To      string
+	CallSid string
+}
+
+var (
+	responseHooksMu sync.Mutex
+	responseHooks   []func(ResponseInfo)
+)
+
+// OnResponse registers fn to be called after every TwiML handler served by
+// a HandlerFunc finishes, with details about the response it sent. fn is
+// called synchronously in the handler's goroutine, so it should not block.
+func OnResponse(fn func(ResponseInfo)) {
+	responseHooksMu.Lock()
+	defer responseHooksMu.Unlock()
+	responseHooks = append(responseHooks, fn)
+}
+
+func fireResponseHooks(info ResponseInfo) {
+	responseHooksMu.Lock()
+	hooks := append([]func(ResponseInfo){}, responseHooks...)
+	responseHooksMu.Unlock()
+	for _, fn := range hooks {
+		fn(info)
+	}
+}
+
+// AccessLog returns a Middleware that writes one JSON line to w for every
+// request it handles, with the same fields as ResponseInfo.
+func AccessLog(w io.Writer) Middleware {
+	var mu sync.Mutex
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			begin := time.Now()
+			r.ParseForm()
+			d := newResponseDelegator(rw)
+			next.ServeHTTP(d, r)
+
+			line, err := json.Marshal(ResponseInfo{
+				Path:     r.URL.Path,
+				Method:   r.Method,
+				Status:   d.status,
+				Bytes:    d.written,
+				Duration: time.Since(begin),
+				From:     r.FormValue("From"),
+				To:       r.FormValue("To"),
+				CallSid:  r.FormValue("CallSid"),
+			})
+			if err != nil {
+				return
+			}
+			line = append(line, '\n')
+
+			mu.Lock()
+			w.Write(line)
+			mu.Unlock()
+		})
+	}
+}
+
+// sizeBuckets are the upper bounds, in bytes, of the TwiML response size
+// histogram a Collector keeps for each path; the final bucket catches
+// everything larger than the last bound.
+var sizeBuckets = [...]int64{256, 1024, 4096}
+
+func sizeBucket(n int64) int {
+	for i, b := range sizeBuckets {
+		if n <= b {
+			return i
+		}
+	}
+	return len(sizeBuckets)
+}
+
+type pathStats struct {
+	calls    int64
+	duration time.Duration
+	sizes    [len(sizeBuckets) + 1]int64
+}
+
+// Collector aggregates per-path call counts, TwiML response size
+// histograms and handler latency, suitable for exposing via Publish to an
+// expvar-to-Prometheus bridge. Register it with OnResponse to feed it:
+//
+//	c := twilio.NewCollector()
+//	twilio.OnResponse(c.Observe)
+//	c.Publish("twilio")
+type Collector struct {
+	mu    sync.Mutex
+	stats map[string]*pathStats
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{stats: map[string]*pathStats{}}
+}
+
+// Observe records info against its Path's statistics. It matches the
+// signature required by OnResponse.
+func (c *Collector) Observe(info ResponseInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.stats[info.Path]
+	if !ok {
+		s = &pathStats{}
+		c.stats[info.Path] = s
+	}
+	s.calls++
+	s.duration += info.Duration
+	s.sizes[sizeBucket(info.Bytes)]++
+}
+
+// Publish exposes c's metrics under name via the expvar package. It panics
+// if name is already in use, per expvar.Publish.
+func (c *Collector) Publish(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return c.snapshot()
+	}))
+}
+
+func (c *Collector) snapshot() map[string]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]interface{}, len(c.stats))
+	for path, s := range c.stats {
+		var avg time.Duration
+		if s.calls > 0 {
+			avg = s.duration / time.Duration(s.calls)
+		}
+		out[path] = map[string]interface{}{
+			"calls":            s.calls,
+			"avgLatency":       avg.String(),
+			"sizeBucketsBytes": sizeBuckets,
+			"sizeBucketCounts": s.sizes,
+		}
+	}
+	return out
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseDelegatorCapturesStatusAndBytes(t *testing.T) {
+	rec := httptest.NewRecorder()
+	d := newResponseDelegator(rec)
+
+	d.WriteHeader(http.StatusTeapot)
+	n, err := d.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Fatalf("Write returned n = %d, want 5", n)
+	}
+	if d.status != http.StatusTeapot {
+		t.Fatalf("status = %d, want %d", d.status, http.StatusTeapot)
+	}
+	if d.written != 5 {
+		t.Fatalf("written = %d, want 5", d.written)
+	}
+}
+
+func TestResponseDelegatorDefaultsStatusToOK(t *testing.T) {
+	rec := httptest.NewRecorder()
+	d := newResponseDelegator(rec)
+
+	if _, err := d.Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if d.status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", d.status, http.StatusOK)
+	}
+}
+
+func TestCollectorObserveBucketsBySize(t *testing.T) {
+	c := NewCollector()
+	c.Observe(ResponseInfo{Path: "/voice", Bytes: 100})
+	c.Observe(ResponseInfo{Path: "/voice", Bytes: 1024})
+	c.Observe(ResponseInfo{Path: "/voice", Bytes: 5000})
+
+	snap := c.snapshot()["/voice"].(map[string]interface{})
+	if calls := snap["calls"]; calls != int64(3) {
+		t.Fatalf("calls = %v, want 3", calls)
+	}
+
+	counts := snap["sizeBucketCounts"].([len(sizeBuckets) + 1]int64)
+	want := [len(sizeBuckets) + 1]int64{0, 1, 0, 1}
+	want[0] = 1
+	if counts != want {
+		t.Fatalf("sizeBucketCounts = %v, want %v", counts, want)
+	}
+}
+
+func TestAccessLogWritesJSONLine(t *testing.T) {
+	var buf bytes.Buffer
+	h := AccessLog(&buf)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/voice", strings.NewReader("From=%2B15551234567&To=%2B15557654321&CallSid=CA123"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	line := buf.String()
+	if !strings.HasSuffix(line, "\n") {
+		t.Fatalf("AccessLog output %q does not end in a newline", line)
+	}
+
+	var info ResponseInfo
+	if err := json.Unmarshal([]byte(line), &info); err != nil {
+		t.Fatalf("AccessLog output %q is not valid JSON: %v", line, err)
+	}
+	if info.Path != "/voice" || info.Status != http.StatusOK || info.Bytes != 2 || info.CallSid != "CA123" {
+		t.Fatalf("AccessLog decoded to %+v, want Path=/voice Status=200 Bytes=2 CallSid=CA123", info)
+	}
+}
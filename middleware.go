@@ -0,0 +1,138 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Middleware wraps an http.Handler with additional behavior, such as
+// logging, recovery or request validation. Middleware chains are composed
+// with Use and applied to a HandlerFunc with HandlerFunc.With.
+type Middleware func(http.Handler) http.Handler
+
+// Use composes the given middleware into a single Middleware that applies
+// them in the order they are passed: the first middleware wraps all the
+// others, so it sees the request first and the response last.
+func Use(mw ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			next = mw[i](next)
+		}
+		return next
+	}
+}
+
+// With wraps fn with the given middleware chain and returns the resulting
+// http.Handler. It is the per-route equivalent of passing mw to Handle.
+func (fn HandlerFunc) With(mw ...Middleware) http.Handler {
+	return Use(mw...)(fn)
+}
+
+// ValidateSignature returns a Middleware that rejects requests with a
+// missing or invalid X-Twilio-Signature header, as described at
+// https://www.twilio.com/docs/usage/security#validating-requests. authToken
+// is the Twilio account's auth token, used as the HMAC key.
+func ValidateSignature(authToken string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseForm(); err != nil {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			sig := r.Header.Get("X-Twilio-Signature")
+			if !validateSignature(authToken, requestURL(r), r.PostForm, sig) {
+				http.Error(w, "invalid twilio signature", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validateSignature checks a request's signature. It is a variable, rather
+// than a plain function call, so that SetSignatureValidator can swap it out
+// in tests that don't want to sign requests with a real auth token.
+var validateSignature = twilioSignatureValid
+
+// SetSignatureValidator overrides the function that ValidateSignature uses
+// to check the X-Twilio-Signature header. Passing nil restores the default,
+// real validator. It exists so tests of TwiML handlers can stub out
+// signature validation.
+func SetSignatureValidator(v func(authToken, url string, form url.Values, signature string) bool) {
+	if v == nil {
+		v = twilioSignatureValid
+	}
+	validateSignature = v
+}
+
+func twilioSignatureValid(authToken, reqURL string, form url.Values, sig string) bool {
+	got, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(twilioSignature(authToken, reqURL, form), got)
+}
+
+// twilioSignature computes the HMAC-SHA1 signature Twilio sends in the
+// X-Twilio-Signature header: the full request URL, followed by the POST
+// form's keys and values concatenated in sorted key order, signed with
+// authToken.
+func twilioSignature(authToken, reqURL string, form url.Values) []byte {
+	keys := make([]string, 0, len(form))
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := []byte(reqURL)
+	for _, k := range keys {
+		buf = append(buf, k...)
+		for _, v := range form[k] {
+			buf = append(buf, v...)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write(buf)
+	return mac.Sum(nil)
+}
+
+// requestURL reconstructs the full URL Twilio used to sign the request.
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if fwd := r.Header.Get("X-Forwarded-Proto"); fwd != "" {
+		scheme = fwd
+	}
+	// r.URL.Path may have had a Mux mount prefix stripped from it; the
+	// original request line still carries the full path Twilio signed.
+	uri := r.URL.RequestURI()
+	if r.RequestURI != "" {
+		if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+			uri = u.RequestURI()
+		}
+	}
+	return scheme + "://" + r.Host + uri
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newSignedRequest(t *testing.T, reqURL string, form url.Values, sig string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, reqURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if sig != "" {
+		req.Header.Set("X-Twilio-Signature", sig)
+	}
+	return req
+}
+
+func TestValidateSignatureAcceptsValidSignature(t *testing.T) {
+	const authToken = "secret"
+	reqURL := "https://example.com/voice"
+	form := url.Values{"CallSid": {"CA123"}, "From": {"+15551234567"}}
+	sig := base64.StdEncoding.EncodeToString(twilioSignature(authToken, reqURL, form))
+
+	called := false
+	h := ValidateSignature(authToken)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), newSignedRequest(t, reqURL, form, sig))
+
+	if !called {
+		t.Fatal("handler not called for a validly signed request")
+	}
+}
+
+func TestValidateSignatureRejectsBadSignature(t *testing.T) {
+	const authToken = "secret"
+	reqURL := "https://example.com/voice"
+	form := url.Values{"CallSid": {"CA123"}}
+
+	called := false
+	h := ValidateSignature(authToken)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newSignedRequest(t, reqURL, form, "bm90YXNpZ25hdHVyZQ=="))
+
+	if called {
+		t.Fatal("handler called despite an invalid signature")
+	}
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestValidateSignatureRejectsMissingHeader(t *testing.T) {
+	const authToken = "secret"
+	reqURL := "https://example.com/voice"
+	form := url.Values{"CallSid": {"CA123"}}
+
+	h := ValidateSignature(authToken)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Fatal("handler called despite a missing signature header")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newSignedRequest(t, reqURL, form, ""))
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("got status %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestSetSignatureValidatorOverride(t *testing.T) {
+	defer SetSignatureValidator(nil)
+
+	SetSignatureValidator(func(authToken, url string, form url.Values, signature string) bool {
+		return signature == "test-mode-ok"
+	})
+
+	called := false
+	h := ValidateSignature("unused")(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	req := newSignedRequest(t, "https://example.com/voice", url.Values{}, "test-mode-ok")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !called {
+		t.Fatal("fake validator installed with SetSignatureValidator was not used")
+	}
+}
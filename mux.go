@@ -0,0 +1,198 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	stdcontext "context"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var (
+	defaultMux     = NewMux()
+	defaultMuxOnce sync.Once
+)
+
+// Mux is a composable TwiML router, allowing a single application to serve
+// a multi-step call flow from one or more HandlerFuncs, each of which may
+// have its own middleware. A Mux implements http.Handler, so it can be
+// used directly or mounted under another Mux.
+type Mux struct {
+	parent      *Mux
+	mountPrefix string // path segment m is mounted under in parent; "" if m is a root Mux
+	mw          []Middleware
+	routed      bool // true once Handle, Mount or Group has registered something on m
+	routes      map[string]http.Handler
+	mounts      []muxMount
+}
+
+type muxMount struct {
+	prefix string
+	mux    *Mux
+	mw     []Middleware // middleware of the Mux Mount was called on
+}
+
+// NewMux creates an empty Mux.
+func NewMux() *Mux {
+	return &Mux{routes: map[string]http.Handler{}}
+}
+
+// Use appends mw to the middleware applied to every route registered on m
+// via Handle, Mount or Group. Like chi, Use must be called before any of
+// those, since mw is baked into each route's handler as it is registered;
+// Use panics if m already has routes, rather than silently not applying mw
+// to the ones registered before it.
+func (m *Mux) Use(mw ...Middleware) {
+	if m.routed {
+		panic("twilio: Use called after a route was already registered on this Mux")
+	}
+	m.mw = append(m.mw, mw...)
+}
+
+// Handle registers fn to serve the given path, relative to m's mount
+// point, wrapped with m's middleware followed by mw.
+func (m *Mux) Handle(path string, fn HandlerFunc, mw ...Middleware) {
+	chain := append(append([]Middleware{}, m.mw...), mw...)
+	m.routes[path] = fn.With(chain...)
+	m.routed = true
+}
+
+// Mount attaches sub to serve every path beneath prefix. Action URLs
+// generated by ctx.URL within sub are resolved relative to m's own mount
+// point joined with prefix. m's mount point is resolved lazily, at serve
+// time, so mounting m itself under a grandparent later still produces the
+// right URLs for sub.
+func (m *Mux) Mount(prefix string, sub *Mux) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	sub.parent = m
+	sub.mountPrefix = prefix
+	m.mounts = append(m.mounts, muxMount{prefix: prefix, mux: sub, mw: append([]Middleware{}, m.mw...)})
+	m.routed = true
+}
+
+// Group calls fn with a Mux that inherits m's middleware, and merges the
+// routes and mounts fn registers back into m. It is used to scope extra
+// middleware, such as signature validation or logging, to a subset of m's
+// routes without mounting them under a new path prefix.
+func (m *Mux) Group(fn func(*Mux)) {
+	sub := &Mux{
+		parent: m,
+		mw:     append([]Middleware{}, m.mw...),
+		routes: map[string]http.Handler{},
+	}
+	fn(sub)
+	for path, h := range sub.routes {
+		m.routes[path] = h
+	}
+	m.mounts = append(m.mounts, sub.mounts...)
+	m.routed = true
+}
+
+// fullPrefix returns m's mount point, resolved by walking up to the
+// outermost Mux it is (transitively) mounted under. It is computed at
+// serve time, rather than cached when Mount is called, so that mounting a
+// Mux under a grandparent later is reflected in its children's URLs too.
+func (m *Mux) fullPrefix() string {
+	if m.parent == nil {
+		return ""
+	}
+	return joinMountPrefix(m.parent.fullPrefix(), m.mountPrefix)
+}
+
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if h, ok := m.routes[path]; ok {
+		h.ServeHTTP(w, withPrefix(r, m.fullPrefix()))
+		return
+	}
+	for _, mnt := range m.mounts {
+		if rest, ok := stripPrefix(path, mnt.prefix); ok {
+			r2 := new(http.Request)
+			*r2 = *r
+			u := *r.URL
+			u.Path = rest
+			r2.URL = &u
+			// Mounted subtrees are served through the middleware of the
+			// Mux they were mounted on (m, or a Group within m), the same
+			// as routes registered with Handle, so that e.g. signature
+			// validation applied to a whole tree with m.Use(...);
+			// m.Mount(...) also covers the mounted routes.
+			mount := mnt.mux
+			Use(mnt.mw...)(http.HandlerFunc(mount.ServeHTTP)).ServeHTTP(w, r2)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// stripPrefix reports whether path lies under the path segment prefix,
+// returning the remainder (always beginning with "/").
+func stripPrefix(path, prefix string) (string, bool) {
+	if prefix == "" {
+		return path, true
+	}
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	rest := path[len(prefix):]
+	if rest == "" {
+		return "/", true
+	}
+	if rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
+
+// joinPath joins a mount prefix and a path into a single absolute path,
+// always returning at least "/".
+func joinPath(prefix, rel string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	rel = strings.TrimPrefix(rel, "/")
+	if prefix == "" {
+		return "/" + rel
+	}
+	if rel == "" {
+		return prefix
+	}
+	return prefix + "/" + rel
+}
+
+// joinMountPrefix composes a parent Mux's fullPrefix with a child's local
+// mountPrefix. Unlike joinPath, joining two empty prefixes yields "", not
+// "/", so that an unmounted (root) Mux's fullPrefix stays "" however many
+// Groups it is composed through.
+func joinMountPrefix(parent, local string) string {
+	if parent == "" && local == "" {
+		return ""
+	}
+	return joinPath(parent, local)
+}
+
+type prefixKey struct{}
+
+// withPrefix returns a shallow copy of r carrying mux's mount point, so
+// that Context.URL can resolve action URLs relative to it.
+func withPrefix(r *http.Request, prefix string) *http.Request {
+	return r.WithContext(stdcontext.WithValue(r.Context(), prefixKey{}, prefix))
+}
+
+func (c *context) URL(rel string) string {
+	prefix, _ := c.r.Context().Value(prefixKey{}).(string)
+	return joinPath(prefix, rel)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMuxNestedMountResolvesURL(t *testing.T) {
+	var got string
+	h := HandlerFunc(func(ctx Context) {
+		got = ctx.URL("confirm")
+	})
+
+	sales := NewMux()
+	sales.Handle("/", h)
+
+	menu := NewMux()
+	menu.Mount("/sales", sales)
+
+	root := NewMux()
+	root.Mount("/menu", menu)
+
+	req := httptest.NewRequest(http.MethodGet, "/menu/sales", nil)
+	root.ServeHTTP(httptest.NewRecorder(), req)
+
+	if want := "/menu/sales/confirm"; got != want {
+		t.Fatalf("ctx.URL(%q) = %q, want %q", "confirm", got, want)
+	}
+}
+
+func TestMuxUseAppliesToRoutesRegisteredAfter(t *testing.T) {
+	var mwCalled bool
+	mw := Middleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mwCalled = true
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	m := NewMux()
+	m.Use(mw)
+	m.Handle("/a", HandlerFunc(func(Context) {}))
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	if !mwCalled {
+		t.Fatal("middleware registered with Use before Handle was not applied")
+	}
+}
+
+func TestMuxUseAppliesToMountedSubtrees(t *testing.T) {
+	var mwCalled bool
+	mw := Middleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mwCalled = true
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	ivr := NewMux()
+	ivr.Handle("/", HandlerFunc(func(Context) {}))
+
+	root := NewMux()
+	root.Use(mw)
+	root.Mount("/ivr", ivr)
+
+	root.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ivr", nil))
+
+	if !mwCalled {
+		t.Fatal("middleware registered on root with Use was not applied to a request dispatched into a mounted subtree")
+	}
+}
+
+func TestMuxUsePanicsAfterRouteRegistered(t *testing.T) {
+	m := NewMux()
+	m.Handle("/a", HandlerFunc(func(Context) {}))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Use did not panic when called after a route was already registered")
+		}
+	}()
+	m.Use(Middleware(func(next http.Handler) http.Handler { return next }))
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errShortToken = errors.New("twilio: session token too short")
+
+// Session holds small bits of state, such as which menu option a caller
+// picked, across the sequence of stateless HTTP callbacks that make up a
+// single call. Obtain one with Context.Session.
+type Session interface {
+	// Get returns the value stored under key, or "" if it is unset.
+	Get(key string) string
+
+	// Set stores value under key.
+	Set(key, value string)
+}
+
+// SessionStore is the backend behind Context.Session. Twilio sends a
+// CallSid with every callback for a given call, which stores that don't
+// need a round-tripped token can use to key their own storage.
+type SessionStore interface {
+	// Load returns the Session for r, creating an empty one if r carries
+	// no existing session state.
+	Load(r *http.Request) Session
+
+	// Token returns the value, if any, that must be appended to action
+	// URLs so that the next callback can find its way back to s. Stores
+	// that key purely off CallSid, which Twilio already resends on every
+	// callback, can return "".
+	Token(s Session) string
+}
+
+var sessionStoreMu sync.Mutex
+var sessionStore SessionStore = NewMemoryStore(30 * time.Minute)
+
+// UseSessionStore replaces the SessionStore used by Context.Session. The
+// default is an in-memory store with a 30 minute TTL.
+func UseSessionStore(s SessionStore) {
+	sessionStoreMu.Lock()
+	defer sessionStoreMu.Unlock()
+	sessionStore = s
+}
+
+func currentSessionStore() SessionStore {
+	sessionStoreMu.Lock()
+	defer sessionStoreMu.Unlock()
+	return sessionStore
+}
+
+func (c *context) Session() Session {
+	if c.session == nil {
+		c.session = currentSessionStore().Load(c.r)
+	}
+	return c.session
+}
+
+// sessionToken reports the token, if any, for the Session already loaded
+// on c. It does not force a Session to be loaded: handlers that never call
+// Session don't pay for one.
+func (c *context) sessionToken() string {
+	if c.session == nil {
+		return ""
+	}
+	return currentSessionStore().Token(c.session)
+}
+
+// injectSessionToken rewrites any action URL e carries to include the
+// current session token, so the next callback can find its way back to
+// the same Session.
+func (c *context) injectSessionToken(e *element) {
+	tok := c.sessionToken()
+	if tok == "" {
+		return
+	}
+	if e.tag == "Redirect" && e.text != "" {
+		e.text = addSessionToken(e.text, tok)
+	}
+	for i, a := range e.attrs {
+		if a.name == "action" || a.name == "waitUrl" {
+			e.attrs[i].value = addSessionToken(a.value, tok)
+		}
+	}
+}
+
+// sessionParam is the hidden query parameter used to round-trip a
+// CookieStore's token through action URLs.
+const sessionParam = "_twilio_session"
+
+func addSessionToken(rawURL, tok string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + sessionParam + "=" + url.QueryEscape(tok)
+}
+
+// memorySession is a Session backed by a map guarded by its own mutex, so
+// that it's safe to read and write from any goroutine holding it.
+type memorySession struct {
+	mu      sync.Mutex
+	values  map[string]string
+	expires time.Time
+}
+
+func (s *memorySession) Get(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+func (s *memorySession) Set(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+}
+
+// MemoryStore is a SessionStore that keeps Session state in memory, keyed
+// by CallSid, evicting sessions that haven't been touched in ttl.
+type MemoryStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]*memorySession
+}
+
+// NewMemoryStore creates a MemoryStore whose sessions expire ttl after
+// their last use.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{ttl: ttl, sessions: map[string]*memorySession{}}
+}
+
+func (m *MemoryStore) Load(r *http.Request) Session {
+	callSid := r.FormValue("CallSid")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.evictLocked()
+
+	s, ok := m.sessions[callSid]
+	if !ok {
+		s = &memorySession{values: map[string]string{}}
+		if callSid != "" {
+			m.sessions[callSid] = s
+		}
+	}
+	s.expires = time.Now().Add(m.ttl)
+	return s
+}
+
+func (m *MemoryStore) evictLocked() {
+	now := time.Now()
+	for callSid, s := range m.sessions {
+		if now.After(s.expires) {
+			delete(m.sessions, callSid)
+		}
+	}
+}
+
+// Token always returns "", since a MemoryStore's sessions are found again
+// by the CallSid Twilio already resends with every callback.
+func (m *MemoryStore) Token(s Session) string {
+	return ""
+}
+
+// cookieSession is a Session whose state lives entirely in the token
+// returned by CookieStore.Token, rather than in any server-side storage.
+type cookieSession struct {
+	values map[string]string
+}
+
+func (s *cookieSession) Get(key string) string {
+	return s.values[key]
+}
+
+func (s *cookieSession) Set(key, value string) {
+	s.values[key] = value
+}
+
+// CookieStore is a stateless SessionStore: it encrypts and authenticates
+// the Session's key/value pairs into a token that round-trips through a
+// hidden query parameter on generated action URLs, since Twilio does not
+// reliably return cookies set on TwiML responses.
+type CookieStore struct {
+	aead cipher.AEAD
+}
+
+// NewCookieStore creates a CookieStore that encrypts session tokens with
+// key, which must be 16, 24 or 32 bytes to select AES-128, AES-192 or
+// AES-256.
+func NewCookieStore(key []byte) (*CookieStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &CookieStore{aead: aead}, nil
+}
+
+func (cs *CookieStore) Load(r *http.Request) Session {
+	values := map[string]string{}
+	if tok := r.FormValue(sessionParam); tok != "" {
+		if v, err := cs.decode(tok); err == nil {
+			values = v
+		}
+	}
+	return &cookieSession{values: values}
+}
+
+func (cs *CookieStore) Token(s Session) string {
+	cookie, ok := s.(*cookieSession)
+	if !ok {
+		return ""
+	}
+	tok, err := cs.encode(cookie.values)
+	if err != nil {
+		return ""
+	}
+	return tok
+}
+
+func (cs *CookieStore) encode(values map[string]string) (string, error) {
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, cs.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := cs.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (cs *CookieStore) decode(tok string) (map[string]string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(tok)
+	if err != nil {
+		return nil, err
+	}
+	n := cs.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, errShortToken
+	}
+	nonce, ciphertext := sealed[:n], sealed[n:]
+	plaintext, err := cs.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]string
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCallRequest(t *testing.T, callSid string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("CallSid="+callSid))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestMemoryStorePersistsAcrossCallbacks(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+
+	store.Load(newCallRequest(t, "CA1")).Set("menu", "sales")
+
+	if got := store.Load(newCallRequest(t, "CA1")).Get("menu"); got != "sales" {
+		t.Fatalf("Get(\"menu\") = %q, want %q", got, "sales")
+	}
+}
+
+func TestMemoryStoreEvictsAfterTTL(t *testing.T) {
+	store := NewMemoryStore(10 * time.Millisecond)
+
+	store.Load(newCallRequest(t, "CA1")).Set("menu", "sales")
+	time.Sleep(20 * time.Millisecond)
+
+	// Loading an unrelated call sweeps expired entries.
+	store.Load(newCallRequest(t, "CA2"))
+
+	if got := store.Load(newCallRequest(t, "CA1")).Get("menu"); got != "" {
+		t.Fatalf("expected session for CA1 to have expired, got menu=%q", got)
+	}
+}
+
+func TestCookieStoreRoundTrip(t *testing.T) {
+	store, err := NewCookieStore(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.Load(newCallRequest(t, "CA1"))
+	s.Set("menu", "sales")
+	tok := store.Token(s)
+	if tok == "" {
+		t.Fatal("Token returned an empty string for a populated session")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/?"+sessionParam+"="+url.QueryEscape(tok), strings.NewReader("CallSid=CA1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	s2 := store.Load(req)
+	if got := s2.Get("menu"); got != "sales" {
+		t.Fatalf("round-tripped Get(\"menu\") = %q, want %q", got, "sales")
+	}
+}
+
+func TestCookieStoreRejectsTamperedToken(t *testing.T) {
+	store, err := NewCookieStore(bytes.Repeat([]byte("k"), 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.Load(newCallRequest(t, "CA1"))
+	s.Set("menu", "sales")
+	tok := store.Token(s)
+
+	// The last base64 character may carry only its high bits, so swap
+	// between two characters whose high bits differ ('A' = 0, 'Q' = 16).
+	last := tok[len(tok)-1:]
+	alt := "A"
+	if last == "A" {
+		alt = "Q"
+	}
+	tampered := tok[:len(tok)-1] + alt
+
+	req := httptest.NewRequest(http.MethodPost, "/?"+sessionParam+"="+url.QueryEscape(tampered), strings.NewReader("CallSid=CA1"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	s2 := store.Load(req)
+	if got := s2.Get("menu"); got != "" {
+		t.Fatalf("tampered session token was accepted, got menu=%q", got)
+	}
+}
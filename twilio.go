@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type Context interface {
@@ -37,8 +38,11 @@ type Context interface {
 	// It returns 0 if the key does not exist or the value cannot be parsed.
 	IntValue(key string) int
 
-	// Response appends the provided string to the TwiML response.
+	// Response appends the provided string verbatim to the TwiML response.
 	// It may be called multiple times from within a handler function.
+	//
+	// Response is an escape hatch for TwiML that the verb builder below
+	// does not (yet) support; s is written as-is and is not escaped.
 	Response(s string)
 
 	// Responsef is like Response but takes a format string and arguments.
@@ -47,6 +51,63 @@ type Context interface {
 
 	// Hangup is a convenience method that sends a <Hangup/> response.
 	Hangup()
+
+	// Say sends a <Say> verb that reads the given text to the caller.
+	Say(text string, opts ...Option)
+
+	// Play sends a <Play> verb that plays the audio found at url.
+	Play(url string, opts ...Option)
+
+	// Pause sends a <Pause> verb that waits silently.
+	Pause(opts ...Option)
+
+	// Gather sends a <Gather> verb that collects digits from the caller.
+	// The returned Gather can be used to nest Say, Play and Pause verbs
+	// that are played while Gather waits for input.
+	Gather(opts ...Option) *Gather
+
+	// Record sends a <Record> verb that records the caller's voice.
+	Record(opts ...Option)
+
+	// Dial sends a <Dial> verb that connects the call to number. If
+	// number is empty, the call is instead connected using the nested
+	// verbs added via the returned Dial.
+	Dial(number string, opts ...Option) *Dial
+
+	// Sms sends the legacy <Sms> verb with the given message body.
+	Sms(body string, opts ...Option)
+
+	// Message sends a <Message> verb with the given message body.
+	Message(body string, opts ...Option)
+
+	// Redirect sends a <Redirect> verb that transfers control of the
+	// call to the TwiML at url.
+	Redirect(url string, opts ...Option)
+
+	// Reject sends a <Reject> verb that rejects the call without
+	// charging the caller.
+	Reject(opts ...Option)
+
+	// Enqueue sends an <Enqueue> verb that adds the caller to the queue
+	// with the given name.
+	Enqueue(name string, opts ...Option)
+
+	// Leave sends a <Leave> verb, returning the caller to the point in
+	// the call flow after the <Enqueue> that queued them.
+	Leave()
+
+	// URL resolves rel against the mount point of the Mux currently
+	// serving the request, returning an absolute path suitable for use
+	// as the action URL of a Gather, Redirect or Dial. Outside of a Mux,
+	// rel is resolved against "/".
+	URL(rel string) string
+
+	// Session returns the call's Session, loading it from the configured
+	// SessionStore on first use. Action URLs generated by the verb
+	// builder automatically carry whatever token the Session's store
+	// needs to find its way back to the same Session on the next
+	// callback.
+	Session() Session
 }
 
 // HandlerFunc is a twilio handler function. It implements http.Handler.
@@ -58,21 +119,62 @@ const (
 )
 
 func (fn HandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	begin := time.Now()
+	d := newResponseDelegator(w)
+
+	c := &context{r: r}
+	fn(c)
 	b := bytes.NewBufferString(start)
-	fn(&context{b, r})
+	for _, n := range c.nodes {
+		if e, ok := n.(*element); ok {
+			c.injectSessionToken(e)
+		}
+		n.writeTo(b)
+	}
 	b.WriteString(end)
-	b.WriteTo(w)
+	b.WriteTo(d)
+
+	fireResponseHooks(ResponseInfo{
+		Path:     r.URL.Path,
+		Method:   r.Method,
+		Status:   d.status,
+		Bytes:    d.written,
+		Duration: time.Since(begin),
+		From:     r.FormValue("From"),
+		To:       r.FormValue("To"),
+		CallSid:  r.FormValue("CallSid"),
+	})
 }
 
 // Handle is a convenience function that registers the specified handler
-// function under the given path using the net/http package's DefaultServeMux.
-func Handle(path string, fn HandlerFunc) {
-	http.Handle(path, fn)
+// function under the given path on a package-level default Mux, wrapped
+// with the given middleware chain, if any. The default Mux is lazily
+// attached to the net/http package's DefaultServeMux the first time
+// Handle is called.
+func Handle(path string, fn HandlerFunc, mw ...Middleware) {
+	defaultMux.Handle(path, fn, mw...)
+	defaultMuxOnce.Do(func() { http.Handle("/", defaultMux) })
 }
 
+// context implements Context. Verb builder methods append nodes to the
+// nodes slice rather than writing to the response directly, so that the
+// whole response tree is serialized once, in ServeHTTP, after fn returns.
 type context struct {
-	b *bytes.Buffer
-	r *http.Request
+	nodes   []node
+	r       *http.Request
+	session Session
+}
+
+func (c *context) add(n node) {
+	c.nodes = append(c.nodes, n)
+}
+
+// addElement adds e to the response. It is the entry point used by every
+// verb builder method. Any action URL e carries is rewritten to include the
+// Session's token, if one is in use, when ServeHTTP serializes the
+// response, so the token reflects the Session as the handler left it.
+func (c *context) addElement(e *element) {
+	c.add(e)
 }
 
 func (c *context) Value(key string) string {
@@ -85,7 +187,7 @@ func (c *context) IntValue(key string) int {
 }
 
 func (c *context) Response(s string) {
-	c.b.WriteString(s)
+	c.add(raw(s))
 }
 
 func (c *context) Responsef(format string, args ...interface{}) {
@@ -93,5 +195,5 @@ func (c *context) Responsef(format string, args ...interface{}) {
 }
 
 func (c *context) Hangup() {
-	c.Response("<Hangup/>")
+	c.add(&element{tag: "Hangup"})
 }
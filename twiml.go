@@ -0,0 +1,237 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strconv"
+)
+
+// node is a piece of the in-memory TwiML tree built up by a handler
+// function. It is serialized once, by ServeHTTP, after the handler
+// returns.
+type node interface {
+	writeTo(b *bytes.Buffer)
+}
+
+// raw is a node that writes itself verbatim, with no escaping. It backs
+// the Response/Responsef escape hatch.
+type raw string
+
+func (r raw) writeTo(b *bytes.Buffer) {
+	b.WriteString(string(r))
+}
+
+// attr is a single TwiML element attribute.
+type attr struct {
+	name, value string
+}
+
+// element is a single TwiML verb, such as <Say> or <Dial>, along with its
+// attributes and either text content or nested verbs.
+type element struct {
+	tag      string
+	attrs    []attr
+	text     string
+	children []node
+}
+
+func (e *element) writeTo(b *bytes.Buffer) {
+	b.WriteByte('<')
+	b.WriteString(e.tag)
+	for _, a := range e.attrs {
+		b.WriteByte(' ')
+		b.WriteString(a.name)
+		b.WriteString(`="`)
+		xml.EscapeText(b, []byte(a.value))
+		b.WriteByte('"')
+	}
+	if e.text == "" && len(e.children) == 0 {
+		b.WriteString("/>")
+		return
+	}
+	b.WriteByte('>')
+	if e.text != "" {
+		xml.EscapeText(b, []byte(e.text))
+	}
+	for _, c := range e.children {
+		c.writeTo(b)
+	}
+	b.WriteString("</")
+	b.WriteString(e.tag)
+	b.WriteByte('>')
+}
+
+// Option configures an attribute of a TwiML verb. Options are applied in
+// the order they are passed to the verb builder method.
+type Option func(*element)
+
+func attrOption(name, value string) Option {
+	return func(e *element) { e.attrs = append(e.attrs, attr{name, value}) }
+}
+
+func intAttrOption(name string, v int) Option {
+	return attrOption(name, strconv.Itoa(v))
+}
+
+func boolAttrOption(name string, v bool) Option {
+	return attrOption(name, strconv.FormatBool(v))
+}
+
+// Common attributes, shared by several verbs.
+func Voice(v string) Option           { return attrOption("voice", v) }
+func Language(v string) Option        { return attrOption("language", v) }
+func Loop(n int) Option               { return intAttrOption("loop", n) }
+func Timeout(seconds int) Option      { return intAttrOption("timeout", seconds) }
+func Action(url string) Option        { return attrOption("action", url) }
+func Method(httpMethod string) Option { return attrOption("method", httpMethod) }
+
+// Gather attributes.
+func FinishOnKey(digit string) Option { return attrOption("finishOnKey", digit) }
+func NumDigits(n int) Option          { return intAttrOption("numDigits", n) }
+
+// Pause attributes.
+func Length(seconds int) Option { return attrOption("length", strconv.Itoa(seconds)) }
+
+// Record attributes.
+func PlayBeep(v bool) Option               { return boolAttrOption("playBeep", v) }
+func MaxLength(seconds int) Option         { return intAttrOption("maxLength", seconds) }
+func Transcribe(v bool) Option             { return boolAttrOption("transcribe", v) }
+func TranscribeCallback(url string) Option { return attrOption("transcribeCallback", url) }
+
+// Dial attributes.
+func CallerID(id string) Option    { return attrOption("callerId", id) }
+func HangupOnStar(v bool) Option   { return boolAttrOption("hangupOnStar", v) }
+func TimeLimit(seconds int) Option { return intAttrOption("timeLimit", seconds) }
+func Record(v bool) Option         { return boolAttrOption("record", v) }
+
+// Sms/Message attributes.
+func To(number string) Option          { return attrOption("to", number) }
+func From(number string) Option        { return attrOption("from", number) }
+func StatusCallback(url string) Option { return attrOption("statusCallback", url) }
+
+// Reject attributes.
+func Reason(reason string) Option { return attrOption("reason", reason) }
+
+// Enqueue attributes.
+func WaitURL(url string) Option     { return attrOption("waitUrl", url) }
+func WaitURLMethod(m string) Option { return attrOption("waitUrlMethod", m) }
+
+// Conference attributes.
+func Muted(v bool) Option                  { return boolAttrOption("muted", v) }
+func Beep(v string) Option                 { return attrOption("beep", v) }
+func StartConferenceOnEnter(v bool) Option { return boolAttrOption("startConferenceOnEnter", v) }
+func EndConferenceOnExit(v bool) Option    { return boolAttrOption("endConferenceOnExit", v) }
+func MaxParticipants(n int) Option         { return intAttrOption("maxParticipants", n) }
+
+func (c *context) Say(text string, opts ...Option) {
+	c.addElement(newElement("Say", text, opts))
+}
+
+func (c *context) Play(url string, opts ...Option) {
+	c.addElement(newElement("Play", url, opts))
+}
+
+func (c *context) Pause(opts ...Option) {
+	c.addElement(newElement("Pause", "", opts))
+}
+
+func (c *context) Record(opts ...Option) {
+	c.addElement(newElement("Record", "", opts))
+}
+
+func (c *context) Sms(body string, opts ...Option) {
+	c.addElement(newElement("Sms", body, opts))
+}
+
+func (c *context) Message(body string, opts ...Option) {
+	c.addElement(newElement("Message", body, opts))
+}
+
+func (c *context) Redirect(url string, opts ...Option) {
+	c.addElement(newElement("Redirect", url, opts))
+}
+
+func (c *context) Reject(opts ...Option) {
+	c.addElement(newElement("Reject", "", opts))
+}
+
+func (c *context) Enqueue(name string, opts ...Option) {
+	c.addElement(newElement("Enqueue", name, opts))
+}
+
+func (c *context) Leave() {
+	c.addElement(&element{tag: "Leave"})
+}
+
+func newElement(tag, text string, opts []Option) *element {
+	e := &element{tag: tag, text: text}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Gather builds the contents of a <Gather> verb. Say, Play and Pause
+// verbs added to a Gather are played to the caller while it waits for
+// digits.
+type Gather struct {
+	e *element
+}
+
+func (c *context) Gather(opts ...Option) *Gather {
+	g := &Gather{e: newElement("Gather", "", opts)}
+	c.addElement(g.e)
+	return g
+}
+
+func (g *Gather) Say(text string, opts ...Option) {
+	g.e.children = append(g.e.children, newElement("Say", text, opts))
+}
+
+func (g *Gather) Play(url string, opts ...Option) {
+	g.e.children = append(g.e.children, newElement("Play", url, opts))
+}
+
+func (g *Gather) Pause(opts ...Option) {
+	g.e.children = append(g.e.children, newElement("Pause", "", opts))
+}
+
+// Dial builds the contents of a <Dial> verb. Number, Client and
+// Conference add the corresponding nested noun to the dial.
+type Dial struct {
+	e *element
+}
+
+func (c *context) Dial(number string, opts ...Option) *Dial {
+	d := &Dial{e: newElement("Dial", number, opts)}
+	c.addElement(d.e)
+	return d
+}
+
+func (d *Dial) Number(number string, opts ...Option) {
+	d.e.children = append(d.e.children, newElement("Number", number, opts))
+}
+
+func (d *Dial) Client(id string, opts ...Option) {
+	d.e.children = append(d.e.children, newElement("Client", id, opts))
+}
+
+func (d *Dial) Conference(name string, opts ...Option) {
+	d.e.children = append(d.e.children, newElement("Conference", name, opts))
+}
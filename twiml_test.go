@@ -0,0 +1,99 @@
+/*
+Copyright 2013 Google Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+     http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package twilio
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, fn HandlerFunc) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	fn.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	return rec.Body.String()
+}
+
+func TestSayEscapesTextAndAttributes(t *testing.T) {
+	got := render(t, func(ctx Context) {
+		ctx.Say(`Press "1" for sales & support`, Voice("Polly.Joanna&Co"))
+	})
+
+	want := `<Say voice="Polly.Joanna&amp;Co">Press &#34;1&#34; for sales &amp; support</Say>`
+	if !strings.Contains(got, want) {
+		t.Fatalf("response %q does not contain escaped %q", got, want)
+	}
+}
+
+func TestGatherNestsSayPlayPause(t *testing.T) {
+	got := render(t, func(ctx Context) {
+		g := ctx.Gather(NumDigits(1))
+		g.Say("choose an option")
+		g.Play("https://example.com/menu.mp3")
+		g.Pause(Length(1))
+	})
+
+	want := `<Gather numDigits="1"><Say>choose an option</Say><Play>https://example.com/menu.mp3</Play><Pause length="1"/></Gather>`
+	if !strings.Contains(got, want) {
+		t.Fatalf("response %q does not contain nested Gather %q", got, want)
+	}
+}
+
+func TestDialNestsNumberClientConference(t *testing.T) {
+	got := render(t, func(ctx Context) {
+		d := ctx.Dial("", CallerID("+15551234567"))
+		d.Number("+15557654321")
+		d.Client("alice")
+		d.Conference("sales-room")
+	})
+
+	want := `<Dial callerId="+15551234567"><Number>+15557654321</Number><Client>alice</Client><Conference>sales-room</Conference></Dial>`
+	if !strings.Contains(got, want) {
+		t.Fatalf("response %q does not contain nested Dial %q", got, want)
+	}
+}
+
+// TestRecordVerbAndRecordDialOption guards against confusing ctx.Record,
+// the <Record> verb, with twilio.Record, the <Dial record="..."> option:
+// a handler can legitimately use both in the same response, and each must
+// only affect its own element.
+func TestRecordVerbAndRecordDialOption(t *testing.T) {
+	got := render(t, func(ctx Context) {
+		ctx.Record(MaxLength(30))
+		d := ctx.Dial("+15557654321", Record(true))
+		_ = d
+	})
+
+	if !strings.Contains(got, `<Record maxLength="30"/>`) {
+		t.Fatalf("response %q missing <Record> verb", got)
+	}
+	if !strings.Contains(got, `<Dial record="true">+15557654321</Dial>`) {
+		t.Fatalf("response %q missing <Dial record=\"true\">", got)
+	}
+}
+
+func TestElementWriteToOmitsClosingTagWhenEmpty(t *testing.T) {
+	var b bytes.Buffer
+	(&element{tag: "Hangup"}).writeTo(&b)
+
+	if got, want := b.String(), "<Hangup/>"; got != want {
+		t.Fatalf("writeTo = %q, want %q", got, want)
+	}
+}